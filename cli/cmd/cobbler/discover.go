@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatYAML   = "yaml"
+	formatNDJSON = "ndjson"
+)
+
+var validDiscoverFormats = map[string]bool{
+	formatTable:  true,
+	formatJSON:   true,
+	formatYAML:   true,
+	formatNDJSON: true,
+}
+
+// defaultTTL is used for entries that advertise a zero TTL, which
+// shouldn't normally happen but would otherwise expire immediately.
+const defaultTTL = 120 * time.Second
+
+// probeTimeout bounds the follow-up GET /status issued per entry by -probe.
+const probeTimeout = 2 * time.Second
+
+// filterFlags collects repeated -filter=key=value flags and matches them
+// against a discovered entry's TXT attributes with AND semantics.
+type filterFlags []struct{ key, value string }
+
+func (f *filterFlags) String() string { return "" }
+
+func (f *filterFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("invalid -filter %q, want key=value", value)
+	}
+	*f = append(*f, struct{ key, value string }{key, val})
+	return nil
+}
+
+func (f filterFlags) matches(entry *zeroconf.ServiceEntry) bool {
+	for _, spec := range f {
+		if entryTXT(entry, spec.key) != spec.value {
+			return false
+		}
+	}
+	return true
+}
+
+func runDiscover(args []string) error {
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	timeout := fs.Duration("timeout", 3*time.Second, "time to wait for responses (ignored with -watch)")
+	format := fs.String("format", formatTable, "output format: table, json, yaml, ndjson")
+	watch := fs.Bool("watch", false, "keep running, printing add/remove events as daemons appear or their TTL expires")
+	probe := fs.Bool("probe", false, "issue a follow-up GET /status to each daemon and show its version/uptime")
+	var filters filterFlags
+	fs.Var(&filters, "filter", "filter by TXT attribute key=value, e.g. -filter=role=worker (repeatable, all must match)")
+	fs.Usage = func() {
+		printDiscoverHelp(os.Stderr)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !validDiscoverFormats[*format] {
+		return fmt.Errorf("unknown -format %q", *format)
+	}
+
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return fmt.Errorf("create resolver: %w", err)
+	}
+
+	if *watch {
+		return watchDiscover(resolver, *format, filters, *probe)
+	}
+	return listDiscover(resolver, *timeout, *format, filters, *probe)
+}
+
+// listDiscover implements the classic "collect until timeout, then print"
+// behaviour.
+func listDiscover(resolver *zeroconf.Resolver, timeout time.Duration, format string, filters filterFlags, probe bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	results := make([]discoveredEntry, 0, 8)
+	done := make(chan struct{})
+
+	go func() {
+		for entry := range entries {
+			if !filters.matches(entry) {
+				continue
+			}
+			results = append(results, newDiscoveredEntry(entry))
+		}
+		close(done)
+	}()
+
+	if err := resolver.Browse(ctx, serviceType, serviceDomain, entries); err != nil {
+		return fmt.Errorf("browse: %w", err)
+	}
+
+	<-ctx.Done()
+	<-done
+
+	if len(results) == 0 && format == formatTable {
+		fmt.Println("No cobbler daemons found.")
+		return nil
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Instance < results[j].Instance
+	})
+
+	if probe {
+		probeEntries(results)
+	}
+
+	return printEntries(os.Stdout, format, results, probe)
+}
+
+// watchedEntry tracks when a live entry's advertised TTL expires, so a
+// missed re-announcement can be surfaced as a remove event.
+type watchedEntry struct {
+	entry     discoveredEntry
+	expiresAt time.Time
+}
+
+// watchDiscover keeps the resolver open indefinitely, diffing incoming
+// entries against a live set keyed by instance name and emitting add/remove
+// events as daemons appear or their TTLs expire, until interrupted.
+func watchDiscover(resolver *zeroconf.Resolver, format string, filters filterFlags, probe bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	if err := resolver.Browse(ctx, serviceType, serviceDomain, entries); err != nil {
+		return fmt.Errorf("browse: %w", err)
+	}
+
+	live := make(map[string]watchedEntry)
+
+	pruneTick := time.NewTicker(time.Second)
+	defer pruneTick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case entry, ok := <-entries:
+			if !ok {
+				return nil
+			}
+			if !filters.matches(entry) {
+				continue
+			}
+
+			ttl := time.Duration(entry.TTL) * time.Second
+			if ttl <= 0 {
+				ttl = defaultTTL
+			}
+
+			de := newDiscoveredEntry(entry)
+			_, alreadyLive := live[entry.Instance]
+
+			if !alreadyLive && probe {
+				probed := []discoveredEntry{de}
+				probeEntries(probed)
+				de = probed[0]
+			}
+			live[entry.Instance] = watchedEntry{entry: de, expiresAt: time.Now().Add(ttl)}
+
+			if !alreadyLive {
+				if err := printEvent(os.Stdout, format, "add", de, probe); err != nil {
+					return err
+				}
+			}
+
+		case now := <-pruneTick.C:
+			for instance, w := range live {
+				if now.Before(w.expiresAt) {
+					continue
+				}
+				delete(live, instance)
+				if err := printEvent(os.Stdout, format, "remove", w.entry, probe); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// statusProbe is the subset of a daemon's /status JSON that -probe surfaces.
+type statusProbe struct {
+	Version string `json:"version"`
+	Uptime  string `json:"uptime"`
+}
+
+// probeEntries concurrently issues a GET /status to each entry's first
+// known address and fills in Version/Uptime on success; entries that can't
+// be reached within probeTimeout are left as-is.
+func probeEntries(entries []discoveredEntry) {
+	client := &http.Client{Timeout: probeTimeout}
+
+	var wg sync.WaitGroup
+	for i := range entries {
+		wg.Add(1)
+		go func(e *discoveredEntry) {
+			defer wg.Done()
+
+			if len(e.Addresses) == 0 {
+				return
+			}
+
+			url := fmt.Sprintf("%s://%s:%d/status", e.Scheme, e.Addresses[0], e.Port)
+			resp, err := client.Get(url)
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+
+			var status statusProbe
+			if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+				return
+			}
+			e.Version = status.Version
+			e.Uptime = status.Uptime
+		}(&entries[i])
+	}
+	wg.Wait()
+}
+
+func printDiscoverHelp(out *os.File) {
+	fmt.Fprintln(out, "Usage: cobbler discover [options]")
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Discovers services advertised as %s in %s.\n", serviceType, serviceDomain)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Options:")
+	fmt.Fprintln(out, "  -timeout duration   time to wait for responses (default 3s, ignored with -watch)")
+	fmt.Fprintln(out, "  -format string      output format: table, json, yaml, ndjson (default table)")
+	fmt.Fprintln(out, "  -filter key=value   filter by TXT attribute, e.g. -filter=role=worker (repeatable)")
+	fmt.Fprintln(out, "  -watch              keep running, printing add/remove events instead of exiting")
+	fmt.Fprintln(out, "  -probe              GET /status from each daemon and show its version/uptime")
+}
+
+// printEntries renders a finished list of entries in the requested format.
+func printEntries(w io.Writer, format string, entries []discoveredEntry, probed bool) error {
+	switch format {
+	case formatTable:
+		printTable(w, entries, probed)
+		return nil
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case formatYAML:
+		return yaml.NewEncoder(w).Encode(entries)
+	case formatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func printTable(w io.Writer, entries []discoveredEntry, probed bool) {
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := "ID\tHOST\tADDRESS\tPORT\tSCHEME\tPROTO\tINSTANCE"
+	if probed {
+		header += "\tVERSION\tUPTIME"
+	}
+	fmt.Fprintln(writer, header)
+	for _, e := range entries {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%d\t%s\t%s\t%s",
+			e.ID,
+			e.Host,
+			strings.Join(e.Addresses, ","),
+			e.Port,
+			e.Scheme,
+			e.Proto,
+			e.Instance,
+		)
+		if probed {
+			fmt.Fprintf(writer, "\t%s\t%s", valueOr(e.Version), valueOr(e.Uptime))
+		}
+		fmt.Fprintln(writer)
+	}
+	_ = writer.Flush()
+}
+
+// valueOr returns s, or "-" if it's empty, for table columns that are only
+// populated when -probe succeeded.
+func valueOr(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// discoverEvent is the watch-mode wire format: an "add" or "remove" around
+// a discoveredEntry.
+type discoverEvent struct {
+	Type  string          `json:"type" yaml:"type"`
+	Entry discoveredEntry `json:"entry" yaml:"entry"`
+}
+
+// printEvent renders a single watch-mode add/remove event in the requested
+// format. Unlike printEntries, this writes one record at a time as events
+// arrive, so table output is a plain line rather than an aligned tabwriter
+// block.
+func printEvent(w io.Writer, format, eventType string, entry discoveredEntry, probed bool) error {
+	switch format {
+	case formatTable:
+		line := fmt.Sprintf(
+			"%-6s id=%s host=%s address=%s port=%d scheme=%s proto=%s instance=%s",
+			strings.ToUpper(eventType),
+			entry.ID,
+			entry.Host,
+			strings.Join(entry.Addresses, ","),
+			entry.Port,
+			entry.Scheme,
+			entry.Proto,
+			entry.Instance,
+		)
+		if probed {
+			line += fmt.Sprintf(" version=%s uptime=%s", valueOr(entry.Version), valueOr(entry.Uptime))
+		}
+		_, err := fmt.Fprintln(w, line)
+		return err
+	case formatJSON, formatNDJSON:
+		return json.NewEncoder(w).Encode(discoverEvent{Type: eventType, Entry: entry})
+	case formatYAML:
+		return yaml.NewEncoder(w).Encode(discoverEvent{Type: eventType, Entry: entry})
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
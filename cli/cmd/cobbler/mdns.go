@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/grandcat/zeroconf"
+)
+
+const (
+	serviceType   = "_cobbler._tcp"
+	serviceDomain = "local."
+)
+
+// discoveredEntry is the format-independent view of a resolved
+// zeroconf.ServiceEntry, shared by discover's table/json/yaml/ndjson
+// renderers and watch-mode events, and by peers' daemon lookup.
+type discoveredEntry struct {
+	ID        string   `json:"id" yaml:"id"`
+	Host      string   `json:"host" yaml:"host"`
+	Addresses []string `json:"addresses" yaml:"addresses"`
+	Port      int      `json:"port" yaml:"port"`
+	Scheme    string   `json:"scheme" yaml:"scheme"`
+	Proto     string   `json:"proto,omitempty" yaml:"proto,omitempty"`
+	Instance  string   `json:"instance" yaml:"instance"`
+
+	// Version and Uptime are only populated when discover is run with
+	// -probe, which fetches them from each daemon's /status endpoint.
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	Uptime  string `json:"uptime,omitempty" yaml:"uptime,omitempty"`
+}
+
+func newDiscoveredEntry(entry *zeroconf.ServiceEntry) discoveredEntry {
+	addrs := make([]string, 0, len(entry.AddrIPv4)+len(entry.AddrIPv6))
+	for _, addr := range entry.AddrIPv4 {
+		addrs = append(addrs, addr.String())
+	}
+	for _, addr := range entry.AddrIPv6 {
+		addrs = append(addrs, addr.String())
+	}
+
+	return discoveredEntry{
+		ID:        entryID(entry),
+		Host:      strings.TrimSuffix(entry.HostName, "."),
+		Addresses: addrs,
+		Port:      entry.Port,
+		Scheme:    entryScheme(entry),
+		Proto:     entryProto(entry),
+		Instance:  entry.Instance,
+	}
+}
+
+func entryID(entry *zeroconf.ServiceEntry) string {
+	return entryTXT(entry, "id")
+}
+
+// entryScheme returns the URL scheme the daemon advertised over mDNS
+// (e.g. "https" once TLS is enabled), defaulting to "http".
+func entryScheme(entry *zeroconf.ServiceEntry) string {
+	if scheme := entryTXT(entry, "scheme"); scheme != "" {
+		return scheme
+	}
+	return "http"
+}
+
+// entryProto returns the advertised application protocol (e.g. "h2"), or
+// "" if the daemon didn't publish one.
+func entryProto(entry *zeroconf.ServiceEntry) string {
+	return entryTXT(entry, "proto")
+}
+
+func entryTXT(entry *zeroconf.ServiceEntry, key string) string {
+	prefix := key + "="
+	for _, txt := range entry.Text {
+		if strings.HasPrefix(txt, prefix) {
+			return strings.TrimPrefix(txt, prefix)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+)
+
+// fetchTimeout bounds the GET /peers request issued by runPeers, mirroring
+// discover's probeTimeout and the daemon's own HealthChecker probeTimeout.
+const fetchTimeout = 2 * time.Second
+
+// peerView mirrors the JSON shape served by a daemon's /peers endpoint.
+type peerView struct {
+	ID        string    `json:"id"`
+	Host      string    `json:"host"`
+	Addresses []string  `json:"addresses"`
+	Port      int       `json:"port"`
+	LastSeen  time.Time `json:"last_seen"`
+	Healthy   bool      `json:"healthy"`
+}
+
+func runPeers(args []string) error {
+	fs := flag.NewFlagSet("peers", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	timeout := fs.Duration("timeout", 3*time.Second, "time to wait while resolving the daemon")
+	fs.Usage = func() {
+		printPeersHelp(os.Stderr)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one daemon id")
+	}
+	id := fs.Arg(0)
+
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return fmt.Errorf("create resolver: %w", err)
+	}
+
+	daemon, err := resolveDaemon(resolver, *timeout, id)
+	if err != nil {
+		return err
+	}
+
+	peers, err := fetchPeers(daemon)
+	if err != nil {
+		return fmt.Errorf("fetch peers from %s: %w", id, err)
+	}
+
+	printPeersTable(os.Stdout, peers)
+	return nil
+}
+
+// resolveDaemon browses for a daemon advertising the given id and returns
+// its resolved entry, or an error if it isn't seen within timeout.
+func resolveDaemon(resolver *zeroconf.Resolver, timeout time.Duration, id string) (discoveredEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	found := make(chan discoveredEntry, 1)
+
+	go func() {
+		for entry := range entries {
+			if entryID(entry) != id {
+				continue
+			}
+			select {
+			case found <- newDiscoveredEntry(entry):
+			default:
+			}
+		}
+	}()
+
+	if err := resolver.Browse(ctx, serviceType, serviceDomain, entries); err != nil {
+		return discoveredEntry{}, fmt.Errorf("browse: %w", err)
+	}
+
+	select {
+	case daemon := <-found:
+		return daemon, nil
+	case <-ctx.Done():
+		return discoveredEntry{}, fmt.Errorf("daemon %q not found", id)
+	}
+}
+
+// fetchPeers dials daemon's own /peers endpoint and decodes its view of the
+// cluster.
+func fetchPeers(daemon discoveredEntry) ([]peerView, error) {
+	if len(daemon.Addresses) == 0 {
+		return nil, fmt.Errorf("no known address for %s", daemon.ID)
+	}
+
+	url := fmt.Sprintf("%s://%s:%d/peers", daemon.Scheme, daemon.Addresses[0], daemon.Port)
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	var peers []peerView
+	if err := json.NewDecoder(resp.Body).Decode(&peers); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return peers, nil
+}
+
+func printPeersTable(w io.Writer, peers []peerView) {
+	if len(peers) == 0 {
+		fmt.Fprintln(w, "No peers known to this daemon.")
+		return
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tHOST\tADDRESS\tPORT\tHEALTHY\tLAST_SEEN")
+	for _, p := range peers {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%d\t%t\t%s\n",
+			p.ID,
+			p.Host,
+			strings.Join(p.Addresses, ","),
+			p.Port,
+			p.Healthy,
+			p.LastSeen.Format(time.RFC3339),
+		)
+	}
+	_ = writer.Flush()
+}
+
+func printPeersHelp(out *os.File) {
+	fmt.Fprintln(out, "Usage: cobbler peers <daemon-id> [options]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Resolves <daemon-id> over mDNS, dials its /peers endpoint, and prints")
+	fmt.Fprintln(out, "that daemon's view of the cluster.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Options:")
+	fmt.Fprintln(out, "  -timeout duration   time to wait while resolving the daemon (default 3s)")
+}
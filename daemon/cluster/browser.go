@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+)
+
+const (
+	serviceType   = "_cobbler._tcp"
+	serviceDomain = "local."
+)
+
+// Browser runs a background mDNS browse, keeping a Registry populated with
+// the sibling daemons currently visible on the local network.
+type Browser struct {
+	registry *Registry
+}
+
+// NewBrowser returns a Browser that feeds discovered entries into registry.
+func NewBrowser(registry *Registry) *Browser {
+	return &Browser{registry: registry}
+}
+
+// Run browses for cobbler daemons until ctx is cancelled, upserting each
+// entry it sees into the registry and pruning ones that age out.
+func (b *Browser) Run(ctx context.Context) error {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return fmt.Errorf("create resolver: %w", err)
+	}
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	if err := resolver.Browse(ctx, serviceType, serviceDomain, entries); err != nil {
+		return fmt.Errorf("browse: %w", err)
+	}
+
+	pruneTick := time.NewTicker(time.Second)
+	defer pruneTick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry, ok := <-entries:
+			if !ok {
+				return nil
+			}
+			b.registry.upsert(entry)
+		case now := <-pruneTick.C:
+			b.registry.pruneExpired(now)
+		}
+	}
+}
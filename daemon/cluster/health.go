@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+// probeTimeout bounds a single /status request to one peer.
+const probeTimeout = 2 * time.Second
+
+// HealthChecker periodically probes every peer in a Registry's /status
+// endpoint, retrying failures with exponential backoff, and records the
+// outcome back onto the peer.
+type HealthChecker struct {
+	registry *Registry
+	interval time.Duration
+	client   *http.Client
+}
+
+// NewHealthChecker returns a HealthChecker that re-probes every peer in
+// registry once per interval.
+func NewHealthChecker(registry *Registry, interval time.Duration) *HealthChecker {
+	return &HealthChecker{
+		registry: registry,
+		interval: interval,
+		client:   &http.Client{Timeout: probeTimeout},
+	}
+}
+
+// Run checks every known peer once per interval until ctx is cancelled.
+func (h *HealthChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, peer := range h.registry.Peers() {
+				go h.check(ctx, peer)
+			}
+		}
+	}
+}
+
+// check probes a single peer, retrying with exponential backoff bounded to
+// one check interval, and records whether it ultimately answered healthy.
+func (h *HealthChecker) check(ctx context.Context, peer Peer) {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = h.interval
+
+	err := backoff.Retry(func() error {
+		return h.probe(ctx, peer)
+	}, backoff.WithContext(b, ctx))
+
+	h.registry.SetHealthy(peer.ID, err == nil)
+}
+
+// ProbeStatus issues a single, on-demand /status request to peer and
+// returns its raw response body, used to serve /peers/{id}/health. Unlike
+// check, it does not retry and does not update the registry's health
+// state.
+func (h *HealthChecker) ProbeStatus(ctx context.Context, peer Peer) ([]byte, error) {
+	if len(peer.Addresses) == 0 {
+		return nil, fmt.Errorf("peer %s has no known address", peer.ID)
+	}
+
+	url := fmt.Sprintf("%s://%s:%d/status", peer.Scheme, peer.Addresses[0], peer.Port)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("probe %s: %w", peer.ID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer %s returned %s", peer.ID, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (h *HealthChecker) probe(ctx context.Context, peer Peer) error {
+	if len(peer.Addresses) == 0 {
+		return backoff.Permanent(fmt.Errorf("peer %s has no known address", peer.ID))
+	}
+
+	url := fmt.Sprintf("%s://%s:%d/status", peer.Scheme, peer.Addresses[0], peer.Port)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer %s returned %s", peer.ID, resp.Status)
+	}
+	return nil
+}
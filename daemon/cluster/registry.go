@@ -0,0 +1,155 @@
+// Package cluster turns mDNS discovery into a lightweight cluster-membership
+// layer: a Registry of sibling daemons kept current by a background Browser,
+// and a HealthChecker that actively probes each one.
+package cluster
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+)
+
+// defaultTTL is used for entries that advertise a zero TTL, which
+// shouldn't normally happen but would otherwise expire immediately.
+const defaultTTL = 120 * time.Second
+
+// Peer is a sibling cobbler daemon discovered over mDNS.
+type Peer struct {
+	ID        string    `json:"id"`
+	Host      string    `json:"host"`
+	Addresses []string  `json:"addresses"`
+	Port      int       `json:"port"`
+	LastSeen  time.Time `json:"last_seen"`
+	Healthy   bool      `json:"healthy"`
+
+	// Scheme is the URL scheme the peer advertised (e.g. "https"). It's
+	// needed to build probe URLs but isn't part of the /peers wire format.
+	Scheme    string `json:"-"`
+	expiresAt time.Time
+}
+
+// Registry is the in-memory, concurrency-safe set of currently known
+// sibling daemons, keyed by their mDNS TXT `id=` attribute. The zero value
+// is not usable; construct one with NewRegistry.
+type Registry struct {
+	selfID string
+
+	mu    sync.RWMutex
+	peers map[string]*Peer
+}
+
+// NewRegistry returns an empty Registry that ignores mDNS entries
+// advertising selfID, so a daemon never lists itself as a peer.
+func NewRegistry(selfID string) *Registry {
+	return &Registry{
+		selfID: selfID,
+		peers:  make(map[string]*Peer),
+	}
+}
+
+// Peers returns a snapshot of currently known peers, sorted by ID.
+func (r *Registry) Peers() []Peer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]Peer, 0, len(r.peers))
+	for _, p := range r.peers {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Get returns the peer with the given ID, if currently known.
+func (r *Registry) Get(id string) (Peer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.peers[id]
+	if !ok {
+		return Peer{}, false
+	}
+	return *p, true
+}
+
+// SetHealthy records the outcome of a health check for id. It's a no-op if
+// the peer has since expired out of the registry.
+func (r *Registry) SetHealthy(id string, healthy bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.peers[id]; ok {
+		p.Healthy = healthy
+	}
+}
+
+// upsert records or refreshes a peer from a resolved mDNS entry. Entries
+// advertising the registry's own selfID are ignored.
+func (r *Registry) upsert(entry *zeroconf.ServiceEntry) {
+	id := txtValue(entry.Text, "id")
+	if id == "" || id == r.selfID {
+		return
+	}
+
+	ttl := time.Duration(entry.TTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	addrs := make([]string, 0, len(entry.AddrIPv4)+len(entry.AddrIPv6))
+	for _, addr := range entry.AddrIPv4 {
+		addrs = append(addrs, addr.String())
+	}
+	for _, addr := range entry.AddrIPv6 {
+		addrs = append(addrs, addr.String())
+	}
+
+	scheme := txtValue(entry.Text, "scheme")
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	healthy := false
+	if existing, ok := r.peers[id]; ok {
+		healthy = existing.Healthy
+	}
+
+	r.peers[id] = &Peer{
+		ID:        id,
+		Host:      strings.TrimSuffix(entry.HostName, "."),
+		Addresses: addrs,
+		Port:      entry.Port,
+		LastSeen:  time.Now(),
+		Healthy:   healthy,
+		Scheme:    scheme,
+		expiresAt: time.Now().Add(ttl),
+	}
+}
+
+// pruneExpired removes peers whose advertised TTL has lapsed as of now.
+func (r *Registry) pruneExpired(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, p := range r.peers {
+		if now.After(p.expiresAt) {
+			delete(r.peers, id)
+		}
+	}
+}
+
+func txtValue(txt []string, key string) string {
+	prefix := key + "="
+	for _, t := range txt {
+		if strings.HasPrefix(t, prefix) {
+			return strings.TrimPrefix(t, prefix)
+		}
+	}
+	return ""
+}
@@ -2,75 +2,297 @@ package main
 
 import (
 	"context"
-	"errors"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
 	"strconv"
-	"syscall"
+	"strings"
 	"time"
 
+	"github.com/cenkalti/backoff"
 	"github.com/grandcat/zeroconf"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/crypto/acme/autocert"
+
+	"cobbler/daemon/cluster"
+	"cobbler/daemon/graceful"
+	"cobbler/daemon/metrics"
 )
 
 const (
-	defaultHTTPPort = 8080
+	defaultHTTPPort      = 8080
+	defaultHammerTimeout = 5 * time.Second
+
+	// acmeHTTPPort is where the ACME HTTP-01 challenge responder listens;
+	// Let's Encrypt always dials port 80 for it.
+	acmeHTTPPort = 80
+
+	// acmeBindGrace is added on top of the hammer timeout when retrying the
+	// ACME port bind across a restart: the old process doesn't release the
+	// port until its own drain (bounded by hammerTimeout) finishes, so the
+	// child needs a little extra room on top of that to win the race.
+	acmeBindGrace = 2 * time.Second
+
+	defaultPeerHealthInterval = 15 * time.Second
+	peersGaugeInterval        = 5 * time.Second
 )
 
 func main() {
+	startTime := time.Now()
+
 	httpPort := envInt("COBBLER_DAEMON_PORT", defaultHTTPPort)
+	hammerTimeout := envDuration("COBBLER_DAEMON_HAMMER_TIMEOUT", defaultHammerTimeout)
 	hostname := hostnameOrUnknown()
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
+	ln, err := graceful.Listen(fmt.Sprintf(":%d", httpPort))
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+
+	met := metrics.New()
+
+	registry := cluster.NewRegistry(hostname)
+	healthChecker := cluster.NewHealthChecker(registry, envDuration("COBBLER_DAEMON_PEER_HEALTH_INTERVAL", defaultPeerHealthInterval))
+
+	clusterCtx, stopCluster := context.WithCancel(context.Background())
+	defer stopCluster()
+	go func() {
+		if err := cluster.NewBrowser(registry).Run(clusterCtx); err != nil {
+			log.Printf("cluster browser: %v", err)
+		}
+	}()
+	go healthChecker.Run(clusterCtx)
+	go reportPeerCount(clusterCtx, registry, met)
+
+	var mdnsRegistered bool
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/status", statusHandler(hostname, buildVersion(), startTime, &mdnsRegistered))
+	mux.Handle("/metrics", promhttp.HandlerFor(met.Registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/peers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(registry.Peers())
+	})
+	mux.HandleFunc("/peers/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/peers/"), "/health")
+		if !ok || id == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		peer, ok := registry.Get(id)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown peer %q", id), http.StatusNotFound)
+			return
+		}
+
+		body, err := healthChecker.ProbeStatus(r.Context(), peer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
 	})
 
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", httpPort),
-		Handler: mux,
+	server := &http.Server{Handler: met.Instrument(mux)}
+
+	accept, scheme, txt, err := setupTLS(ln, hammerTimeout)
+	if err != nil {
+		log.Fatalf("tls: %v", err)
 	}
+	txt = append([]string{fmt.Sprintf("id=%s", hostname)}, txt...)
 
+	// Registered fresh on every process start, this also covers the child
+	// side of a graceful restart: re-exec runs main() from scratch, so the
+	// new process re-registers under the same instance name and `discover`
+	// never sees a gap.
 	mdnsServer, err := zeroconf.Register(
 		fmt.Sprintf("cobblerd-%s", hostname),
 		"_cobbler._tcp",
 		"local.",
 		httpPort,
-		[]string{fmt.Sprintf("id=%s", hostname)},
+		txt,
 		nil,
 	)
 	if err != nil {
 		log.Printf("mDNS disabled: %v", err)
+	} else {
+		mdnsRegistered = true
+		met.MDNSRegistrations.Inc()
 	}
 
-	go func() {
-		<-ctx.Done()
+	log.Printf("cobbler daemon listening on %s://:%d", scheme, httpPort)
+	restarted, serveErr := graceful.Serve(context.Background(), ln, accept, server, hammerTimeout)
+
+	// On a successful restart the child has already re-registered under the
+	// same instance name, so sending our own goodbye here would retract its
+	// fresh record instead of ours.
+	if mdnsServer != nil && !restarted {
+		mdnsServer.Shutdown()
+	}
+
+	if serveErr != nil {
+		log.Fatalf("http server error: %v", serveErr)
+	}
+}
+
+// statusResponse is the JSON document served from /status.
+type statusResponse struct {
+	Hostname       string `json:"hostname"`
+	ID             string `json:"id"`
+	Version        string `json:"version"`
+	GoVersion      string `json:"go_version"`
+	Uptime         string `json:"uptime"`
+	Goroutines     int    `json:"goroutines"`
+	MDNSRegistered bool   `json:"mdns_registered"`
+}
+
+// statusHandler reports process and mDNS registration state. mdnsRegistered
+// is read at request time, not closed-over by value, since registration
+// happens after the mux is built.
+func statusHandler(hostname, version string, startTime time.Time, mdnsRegistered *bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(statusResponse{
+			Hostname:       hostname,
+			ID:             hostname,
+			Version:        version,
+			GoVersion:      runtime.Version(),
+			Uptime:         time.Since(startTime).String(),
+			Goroutines:     runtime.NumGoroutine(),
+			MDNSRegistered: *mdnsRegistered,
+		})
+	}
+}
 
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			log.Printf("http shutdown error: %v", err)
+// buildVersion reports the module version embedded by the Go toolchain, or
+// "(unknown)" for a build that didn't capture one (e.g. `go build` outside
+// a tagged module).
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(unknown)"
+	}
+	return info.Main.Version
+}
+
+// reportPeerCount keeps the peers-discovered gauge in sync with the
+// registry until ctx is cancelled.
+func reportPeerCount(ctx context.Context, registry *cluster.Registry, met *metrics.Metrics) {
+	ticker := time.NewTicker(peersGaugeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			met.PeersDiscovered.Set(float64(len(registry.Peers())))
 		}
+	}
+}
+
+// setupTLS inspects COBBLER_DAEMON_TLS and, if set, wraps ln in a TLS
+// listener — either from a static cert/key pair or from an autocert
+// Manager that obtains a Let's Encrypt certificate on demand. It returns
+// the listener the HTTP server should accept on, the scheme to advertise,
+// and any extra mDNS TXT attributes describing that scheme. hammerTimeout
+// is only used by the autocert case, to bound how long it retries binding
+// its challenge-responder port across a SIGHUP restart.
+func setupTLS(ln *graceful.Listener, hammerTimeout time.Duration) (net.Listener, string, []string, error) {
+	switch mode := os.Getenv("COBBLER_DAEMON_TLS"); mode {
+	case "", "off":
+		return ln, "http", nil, nil
 
-		if mdnsServer != nil {
-			mdnsServer.Shutdown()
+	case "static":
+		certFile := os.Getenv("COBBLER_DAEMON_TLS_CERT")
+		keyFile := os.Getenv("COBBLER_DAEMON_TLS_KEY")
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("load cert/key: %w", err)
 		}
-	}()
 
-	log.Printf("cobbler daemon listening on %s", server.Addr)
-	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Fatalf("http server error: %v", err)
+		cfg := &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"h2", "http/1.1"},
+		}
+		return tls.NewListener(ln, cfg), "https", []string{"scheme=https", "proto=h2"}, nil
+
+	case "autocert":
+		host := os.Getenv("COBBLER_DAEMON_TLS_HOST")
+		if host == "" {
+			return nil, "", nil, fmt.Errorf("COBBLER_DAEMON_TLS_HOST is required for autocert")
+		}
+
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(host),
+			Cache:      autocert.DirCache(envString("COBBLER_DAEMON_TLS_CACHE", filepath.Join(os.TempDir(), "cobbler-autocert"))),
+		}
+
+		go func() {
+			addr := fmt.Sprintf(":%d", acmeHTTPPort)
+			acmeLn, err := listenWithRetry(addr, hammerTimeout+acmeBindGrace)
+			if err != nil {
+				log.Printf("ACME HTTP-01 challenge server on %s: %v", addr, err)
+				return
+			}
+			if err := http.Serve(acmeLn, manager.HTTPHandler(nil)); err != nil {
+				log.Printf("ACME HTTP-01 challenge server on %s: %v", addr, err)
+			}
+		}()
+
+		cfg := manager.TLSConfig()
+		cfg.NextProtos = append([]string{"h2"}, cfg.NextProtos...)
+		return tls.NewListener(ln, cfg), "https", []string{"scheme=https", "proto=h2"}, nil
+
+	default:
+		return nil, "", nil, fmt.Errorf("unknown COBBLER_DAEMON_TLS mode %q", mode)
+	}
+}
+
+// listenWithRetry binds addr, retrying with exponential backoff for up to
+// maxElapsed. It exists because the ACME challenge responder's port is
+// still held by the old process for the duration of its drain across a
+// SIGHUP restart, so the child's first bind attempt is expected to fail.
+func listenWithRetry(addr string, maxElapsed time.Duration) (net.Listener, error) {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = maxElapsed
+
+	var ln net.Listener
+	err := backoff.Retry(func() error {
+		var err error
+		ln, err = net.Listen("tcp", addr)
+		return err
+	}, b)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", addr, err)
 	}
+	return ln, nil
 }
 
 func envInt(key string, fallback int) int {
@@ -88,6 +310,28 @@ func envInt(key string, fallback int) int {
 	return parsed
 }
 
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid %s=%q, using %s", key, value, fallback)
+		return fallback
+	}
+
+	return parsed
+}
+
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func hostnameOrUnknown() string {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -0,0 +1,190 @@
+// Package graceful implements zero-downtime restarts for a single
+// long-running HTTP server: systemd-style socket activation on startup,
+// and a SIGHUP-triggered re-exec that hands the listening socket to a
+// freshly started child while the old process drains in-flight requests.
+package graceful
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	listenFDsEnv = "LISTEN_FDS"
+	listenPIDEnv = "LISTEN_PID"
+
+	// listenFDStart is fd 3, the first descriptor after stdin/stdout/stderr,
+	// per the systemd socket-activation protocol.
+	listenFDStart = 3
+)
+
+// Listener wraps a net.Listener that may have been inherited from a parent
+// process, so that it can later be handed down again via Restart.
+type Listener struct {
+	net.Listener
+}
+
+// Listen returns a Listener for addr. If LISTEN_FDS/LISTEN_PID indicate that
+// a socket was handed down by a parent process, it wraps that fd instead of
+// opening a new one; otherwise it behaves like net.Listen("tcp", addr).
+func Listen(addr string) (*Listener, error) {
+	if fd, ok := inheritedFD(); ok {
+		file := os.NewFile(fd, "cobbler-daemon-listener")
+		ln, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("wrap inherited fd %d: %w", fd, err)
+		}
+		return &Listener{Listener: ln}, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{Listener: ln}, nil
+}
+
+func inheritedFD() (uintptr, bool) {
+	nfds, err := strconv.Atoi(os.Getenv(listenFDsEnv))
+	if err != nil || nfds < 1 {
+		return 0, false
+	}
+
+	// Genuine systemd socket activation sets LISTEN_PID to the pid of the
+	// process meant to receive the descriptors, since it knows that pid
+	// before the exec. Our own Restart can't learn the child's pid until
+	// after it has already started, so it leaves LISTEN_PID unset; accept
+	// that case too, alongside a real match.
+	if pid := os.Getenv(listenPIDEnv); pid != "" {
+		parsed, err := strconv.Atoi(pid)
+		if err != nil || parsed != os.Getpid() {
+			return 0, false
+		}
+	}
+
+	return listenFDStart, true
+}
+
+// Restart re-execs the current binary with the same argv and environment,
+// handing it the listening socket via ExtraFiles and LISTEN_FDS so it can
+// start accepting connections immediately, without dropping any.
+func Restart(ln *Listener) (*os.Process, error) {
+	syscallConn, ok := ln.Listener.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("listener of type %T cannot be passed to a child", ln.Listener)
+	}
+
+	file, err := syscallConn.File()
+	if err != nil {
+		return nil, fmt.Errorf("dup listener fd: %w", err)
+	}
+	defer file.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("resolve executable: %w", err)
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(envWithoutListenFDs(os.Environ()), fmt.Sprintf("%s=1", listenFDsEnv))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{file}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start child: %w", err)
+	}
+
+	return cmd.Process, nil
+}
+
+// envWithoutListenFDs drops any inherited LISTEN_FDS/LISTEN_PID from env.
+// Without this, a daemon that was itself started under genuine systemd
+// socket activation would carry the original LISTEN_PID through every
+// Restart() fork; the child would then compare that stale pid against its
+// own and treat the handed-down fd as not meant for it.
+func envWithoutListenFDs(env []string) []string {
+	filtered := make([]string, 0, len(env))
+	for _, kv := range env {
+		if strings.HasPrefix(kv, listenFDsEnv+"=") || strings.HasPrefix(kv, listenPIDEnv+"=") {
+			continue
+		}
+		filtered = append(filtered, kv)
+	}
+	return filtered
+}
+
+// Serve runs server until ctx is cancelled or a signal arrives: SIGHUP
+// re-execs the binary via Restart and then drains, SIGINT/SIGTERM drain
+// directly. Draining gives in-flight requests up to hammer before the
+// server is forcibly closed. Serve returns once the server has stopped.
+//
+// accept is what the server actually calls Accept on, and may wrap ln (for
+// example in TLS); ln itself is only used to hand the raw socket down to
+// the child on restart, so it must be the listener Restart can dup an fd
+// from.
+//
+// A SIGHUP whose Restart fails leaves the old process serving: there is no
+// child listening yet, so draining here would take the daemon down with
+// nothing in its place. The signal is logged and Serve keeps waiting for a
+// SIGHUP that succeeds, or for ctx/SIGINT/SIGTERM to end it directly.
+//
+// The returned bool reports whether the drain was triggered by a successful
+// restart, as opposed to a genuine shutdown (ctx/SIGINT/SIGTERM). Callers
+// that advertise the server's presence externally (e.g. mDNS) need this to
+// avoid retracting that advertisement out from under the child process that
+// already re-advertised it.
+func Serve(ctx context.Context, ln *Listener, accept net.Listener, server *http.Server, hammer time.Duration) (bool, error) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(accept) }()
+
+	var restarted bool
+	for {
+		select {
+		case <-ctx.Done():
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				if _, err := Restart(ln); err != nil {
+					log.Printf("graceful: restart failed, continuing to serve: %v", err)
+					continue
+				}
+				restarted = true
+			}
+		case err := <-serveErr:
+			if errors.Is(err, http.ErrServerClosed) {
+				return false, nil
+			}
+			return false, err
+		}
+		break
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), hammer)
+	defer cancel()
+	var shutdownErr error
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		shutdownErr = fmt.Errorf("shutdown: %w", err)
+	}
+
+	<-serveErr
+	return restarted, shutdownErr
+}
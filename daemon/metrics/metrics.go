@@ -0,0 +1,77 @@
+// Package metrics wires up the daemon's Prometheus instrumentation: HTTP
+// request counters, mDNS re-registration attempts, and discovered-peer
+// count.
+package metrics
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the daemon's Prometheus collectors, registered against a
+// private Registry so /metrics only ever exposes cobbler's own series.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	HTTPRequestsTotal    *prometheus.CounterVec
+	HTTPRequestsInFlight prometheus.Gauge
+	MDNSRegistrations    prometheus.Counter
+	PeersDiscovered      prometheus.Gauge
+}
+
+// New constructs and registers the daemon's collectors.
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		HTTPRequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cobbler_daemon_http_requests_total",
+			Help: "Total HTTP requests handled, by path and method.",
+		}, []string{"path", "method"}),
+		HTTPRequestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cobbler_daemon_http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served.",
+		}),
+		MDNSRegistrations: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "cobbler_daemon_mdns_registrations_total",
+			Help: "Number of times the daemon has (re-)registered its mDNS record.",
+		}),
+		PeersDiscovered: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "cobbler_daemon_peers_discovered",
+			Help: "Number of sibling daemons currently known via mDNS discovery.",
+		}),
+	}
+
+	m.Registry.MustRegister(
+		m.HTTPRequestsTotal,
+		m.HTTPRequestsInFlight,
+		m.MDNSRegistrations,
+		m.PeersDiscovered,
+	)
+
+	return m
+}
+
+// Instrument wraps h to track in-flight requests and per-path/method
+// totals.
+func (m *Metrics) Instrument(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.HTTPRequestsInFlight.Inc()
+		defer m.HTTPRequestsInFlight.Dec()
+
+		m.HTTPRequestsTotal.WithLabelValues(pathLabel(r.URL.Path), r.Method).Inc()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// pathLabel buckets a request path into a fixed-cardinality metric label.
+// /peers/{id}/health carries a caller-controlled id segment, so it's
+// normalized to a single "/peers/:id/health" series rather than creating one
+// time series per id ever queried.
+func pathLabel(path string) string {
+	if id, ok := strings.CutSuffix(strings.TrimPrefix(path, "/peers/"), "/health"); ok && id != "" && !strings.Contains(id, "/") {
+		return "/peers/:id/health"
+	}
+	return path
+}